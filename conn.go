@@ -3,34 +3,77 @@ package fquic
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"golang.org/x/sync/errgroup"
 )
 
+// ErrConnClosing is returned by AcceptStream once Shutdown has been
+// called, even if the connection hasn't fully closed yet.
+var ErrConnClosing = errors.New("fquic: connection is closing")
+
+// closedChan is always-ready, returned by HandshakeComplete for
+// connections that aren't early ones, since the handshake is already
+// complete for those by the time Dial/Client returns.
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
 type Conn struct {
 	session quic.Session
 
+	// earlySession is non-nil if this Conn was established via
+	// DialEarly/ClientEarly, since only quic.EarlySession (not plain
+	// quic.Session) exposes HandshakeComplete.
+	earlySession quic.EarlySession
+
 	streams    chan *Stream
 	streamErr  error
 	streamLock sync.RWMutex
 
 	closer sync.Once
 	done   chan struct{}
+
+	shutdownOnce sync.Once
+	shutdown     chan struct{}
+	streamWG     sync.WaitGroup
+
+	// IdleTimeout, if non-zero, is the amount of time the Conn will wait
+	// after its last active stream is closed before calling Shutdown
+	// automatically; a stream opened or accepted in that time cancels the
+	// wait. The wait only starts when the number of active streams drops
+	// to zero, so a Conn that has never had a stream is not shut down.
+	IdleTimeout time.Duration
+
+	idleMu    sync.Mutex
+	idleTimer *time.Timer
+	numOpen   int64
 }
 
 func newConn(session quic.Session) *Conn {
 	c := Conn{
-		session: session,
-		streams: make(chan *Stream),
-		done:    make(chan struct{}),
+		session:  session,
+		streams:  make(chan *Stream),
+		done:     make(chan struct{}),
+		shutdown: make(chan struct{}),
 	}
 	go c.acceptStreams()
 	return &c
 }
 
+func newEarlyConn(session quic.EarlySession) *Conn {
+	c := newConn(session)
+	c.earlySession = session
+	return c
+}
+
 func Dial(address string) (*Conn, error) {
 	return new(Dialer).Dial(address)
 }
@@ -100,10 +143,19 @@ func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
 	// TODO: Make sure that this returns the correct errors in different
 	// types of situations.
 
+	select {
+	case <-c.shutdown:
+		return nil, ErrConnClosing
+	default:
+	}
+
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 
+	case <-c.shutdown:
+		return nil, ErrConnClosing
+
 	case s, ok := <-c.streams:
 		if !ok {
 			c.streamLock.RLock()
@@ -115,6 +167,78 @@ func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
 	}
 }
 
+// Shutdown stops the Conn from delivering new streams (AcceptStream
+// returns ErrConnClosing from then on; streams accepted from the peer but
+// not yet returned by AcceptStream are cancelled) and waits for all
+// streams handed out by AcceptStream or NewStream to be closed, or for
+// ctx to be done, before closing the underlying session with a zero
+// error code and a blank description. If ctx is done first, its error is
+// returned.
+func (c *Conn) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		close(c.shutdown)
+
+		go func() {
+			for s := range c.streams {
+				s.CancelWrite(0)
+				s.CloseRead(0)
+			}
+		}()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		c.streamWG.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		c.CloseWithError(0, "")
+		return ctx.Err()
+	}
+
+	return c.CloseWithError(0, "")
+}
+
+// streamOpened is called by newStream to track the Conn's outstanding
+// streams, both for Shutdown and for idle-timeout based auto-shutdown.
+func (c *Conn) streamOpened() {
+	c.streamWG.Add(1)
+
+	if atomic.AddInt64(&c.numOpen, 1) == 1 {
+		c.idleMu.Lock()
+		defer c.idleMu.Unlock()
+
+		if c.idleTimer != nil {
+			c.idleTimer.Stop()
+			c.idleTimer = nil
+		}
+	}
+}
+
+// streamClosed is called once a Stream returned by this Conn is closed.
+func (c *Conn) streamClosed() {
+	c.streamWG.Done()
+
+	if atomic.AddInt64(&c.numOpen, -1) == 0 && c.IdleTimeout > 0 {
+		c.idleMu.Lock()
+		defer c.idleMu.Unlock()
+
+		c.idleTimer = time.AfterFunc(c.IdleTimeout, func() {
+			c.Shutdown(context.Background())
+		})
+	}
+}
+
+// NewStream opens a new stream.
+//
+// If c was established via DialEarly or ClientEarly and HandshakeComplete
+// has not yet fired, the connection may still be running on 0-RTT data:
+// anything written to the returned Stream before the handshake is
+// confirmed can be replayed by a network attacker. Only write
+// replay-safe (e.g. idempotent) requests until WaitForHandshake returns.
 func (c *Conn) NewStream(unidirectional bool) (*Stream, error) {
 	if unidirectional {
 		s, err := c.session.OpenUniStream()
@@ -143,6 +267,37 @@ func (c *Conn) Session() quic.Session {
 	return c.session
 }
 
+// NumOpenStreams returns the number of streams opened by NewStream or
+// accepted from the peer that haven't been closed yet, including a
+// stream accepted but not yet returned by AcceptStream.
+func (c *Conn) NumOpenStreams() int64 {
+	return atomic.LoadInt64(&c.numOpen)
+}
+
+// HandshakeComplete returns a channel that is closed once the QUIC
+// handshake is fully confirmed. For connections established via
+// DialEarly or ClientEarly, this happens after the 0-RTT handshake is
+// confirmed by the server, not when the early data is first sent. For
+// any other connection, the handshake is already complete by the time
+// Dial/Client returns, so the returned channel is already closed.
+func (c *Conn) HandshakeComplete() <-chan struct{} {
+	if c.earlySession != nil {
+		return c.earlySession.HandshakeComplete().Done()
+	}
+	return closedChan
+}
+
+// WaitForHandshake blocks until the handshake is complete or ctx is
+// done, whichever comes first.
+func (c *Conn) WaitForHandshake(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.HandshakeComplete():
+		return nil
+	}
+}
+
 type Dialer struct {
 	// TLSConfig is the TLS configuration to use when dialing a new
 	// connection. If it is a nil, a sane default configuration is used.
@@ -158,6 +313,28 @@ type Dialer struct {
 	// are specified, Protocol will be prepended to the list specified
 	// in NextProtos.
 	Protocol string
+
+	// Allow0RTT enables 0-RTT early data when dialing with DialEarly or
+	// ClientEarly. It is ignored by Dial and Client. To actually send
+	// 0-RTT data, TLSConfig must be configured with a
+	// tls.ClientSessionCache that has a cached session for the server
+	// being dialed; without one, the handshake proceeds normally and
+	// HandshakeComplete simply fires before any data is sent.
+	Allow0RTT bool
+
+	// EnableDatagrams turns on support for unreliable datagrams (RFC
+	// 9221) if QuicConfig doesn't already specify a value, allowing
+	// Conn.SendDatagram and Conn.ReceiveDatagram to be used.
+	EnableDatagrams bool
+
+	// MaxIdleStreams is the default Conn.IdleTimeout applied to
+	// connections created by this Dialer: once a Conn's last active
+	// stream has been closed for this long with no new one opened, it
+	// shuts itself down. A Conn that has never had a stream is not shut
+	// down. If it is zero, Conn.IdleTimeout is left unset and idle
+	// connections are not shut down automatically. Useful for eviction
+	// in a connection pool.
+	MaxIdleStreams time.Duration
 }
 
 func (d *Dialer) tlsConfig() *tls.Config {
@@ -177,17 +354,45 @@ func (d *Dialer) tlsConfig() *tls.Config {
 	return conf
 }
 
+func (d *Dialer) newConn(session quic.Session) *Conn {
+	c := newConn(session)
+	c.IdleTimeout = d.MaxIdleStreams
+	return c
+}
+
+func (d *Dialer) newEarlyConn(session quic.EarlySession) *Conn {
+	c := newEarlyConn(session)
+	c.IdleTimeout = d.MaxIdleStreams
+	return c
+}
+
+func (d *Dialer) quicConfig() *quic.Config {
+	conf := d.QuicConfig
+	if conf == nil {
+		conf = new(quic.Config)
+	} else {
+		clone := *conf
+		conf = &clone
+	}
+
+	if d.EnableDatagrams {
+		conf.EnableDatagrams = true
+	}
+
+	return conf
+}
+
 func (d *Dialer) Dial(address string) (*Conn, error) {
 	return d.DialContext(context.Background(), address)
 }
 
 func (d *Dialer) DialContext(ctx context.Context, address string) (*Conn, error) {
-	session, err := quic.DialAddrContext(ctx, address, d.tlsConfig(), d.QuicConfig)
+	session, err := quic.DialAddrContext(ctx, address, d.tlsConfig(), d.quicConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	return newConn(session), nil
+	return d.newConn(session), nil
 }
 
 func (d *Dialer) Client(conn net.PacketConn, raddr net.Addr, host string) (*Conn, error) {
@@ -195,10 +400,56 @@ func (d *Dialer) Client(conn net.PacketConn, raddr net.Addr, host string) (*Conn
 }
 
 func (d *Dialer) ClientContext(ctx context.Context, conn net.PacketConn, raddr net.Addr, host string) (*Conn, error) {
-	session, err := quic.DialContext(ctx, conn, raddr, host, d.tlsConfig(), d.QuicConfig)
+	session, err := quic.DialContext(ctx, conn, raddr, host, d.tlsConfig(), d.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newConn(session), nil
+}
+
+// DialEarly is like Dial, but allows sending 0-RTT data before the
+// handshake is complete if a session ticket for address is cached in
+// TLSConfig.ClientSessionCache. Allow0RTT must be set.
+func (d *Dialer) DialEarly(address string) (*Conn, error) {
+	return d.DialEarlyContext(context.Background(), address)
+}
+
+// DialEarlyContext is like DialContext, but allows sending 0-RTT data
+// before the handshake is complete if a session ticket for address is
+// cached in TLSConfig.ClientSessionCache. Allow0RTT must be set.
+func (d *Dialer) DialEarlyContext(ctx context.Context, address string) (*Conn, error) {
+	if !d.Allow0RTT {
+		panic("fquic: Allow0RTT must be set to use DialEarly")
+	}
+
+	session, err := quic.DialAddrEarlyContext(ctx, address, d.tlsConfig(), d.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newEarlyConn(session), nil
+}
+
+// ClientEarly is like Client, but allows sending 0-RTT data before the
+// handshake is complete if a session ticket for host is cached in
+// TLSConfig.ClientSessionCache. Allow0RTT must be set.
+func (d *Dialer) ClientEarly(conn net.PacketConn, raddr net.Addr, host string) (*Conn, error) {
+	return d.ClientEarlyContext(context.Background(), conn, raddr, host)
+}
+
+// ClientEarlyContext is like ClientContext, but allows sending 0-RTT
+// data before the handshake is complete if a session ticket for host is
+// cached in TLSConfig.ClientSessionCache. Allow0RTT must be set.
+func (d *Dialer) ClientEarlyContext(ctx context.Context, conn net.PacketConn, raddr net.Addr, host string) (*Conn, error) {
+	if !d.Allow0RTT {
+		panic("fquic: Allow0RTT must be set to use ClientEarly")
+	}
+
+	session, err := quic.DialEarlyContext(ctx, conn, raddr, host, d.tlsConfig(), d.quicConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	return newConn(session), nil
+	return d.newEarlyConn(session), nil
 }
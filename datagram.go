@@ -0,0 +1,34 @@
+package fquic
+
+import "context"
+
+// SendDatagram sends b as an unreliable datagram (RFC 9221). The Conn
+// must have been created with EnableDatagrams set, either directly on
+// QuicConfig or via Dialer.EnableDatagrams/ListenerConfig.EnableDatagrams.
+func (c *Conn) SendDatagram(b []byte) error {
+	return c.session.SendMessage(b)
+}
+
+type datagramResult struct {
+	b   []byte
+	err error
+}
+
+// ReceiveDatagram waits for and returns the next unreliable datagram, or
+// an error if ctx is done or the Conn is closed. quic-go's ReceiveMessage
+// has no cancellation of its own, so a call left blocked past ctx's
+// deadline keeps running in the background until the Conn closes.
+func (c *Conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	result := make(chan datagramResult, 1)
+	go func() {
+		b, err := c.session.ReceiveMessage()
+		result <- datagramResult{b, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-result:
+		return r.b, r.err
+	}
+}
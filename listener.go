@@ -0,0 +1,119 @@
+package fquic
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// Listener accepts incoming QUIC connections and hands them back as
+// *Conn, mirroring the API that Dialer provides on the client side.
+type Listener struct {
+	listener quic.Listener
+}
+
+// Listen starts listening for QUIC connections on address using tlsConf
+// and quicConf.
+func Listen(address string, tlsConf *tls.Config, quicConf *quic.Config) (*Listener, error) {
+	return (&ListenerConfig{
+		TLSConfig:  tlsConf,
+		QuicConfig: quicConf,
+	}).Listen(address)
+}
+
+func newListener(listener quic.Listener) *Listener {
+	return &Listener{listener: listener}
+}
+
+// Accept waits for and returns the next connection, or an error if the
+// context is cancelled or the Listener is closed.
+func (l *Listener) Accept(ctx context.Context) (*Conn, error) {
+	session, err := l.listener.Accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(session), nil
+}
+
+// Addr returns the local network address the Listener is listening on.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Close closes the Listener. Any blocked Accept calls will be unblocked
+// and return errors.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+// ListenerConfig is the server-side counterpart to Dialer. It controls
+// the TLS and quic-go configuration used by Listen.
+type ListenerConfig struct {
+	// TLSConfig is the TLS configuration to use when accepting new
+	// connections. If it is nil, an empty tls.Config is used, which
+	// holds no certificate.
+	TLSConfig *tls.Config
+
+	// QuicConfig is the quic-go configuration to use when accepting new
+	// connections. If it is nil, a sane default configuration is used.
+	QuicConfig *quic.Config
+
+	// Protocol, if non-empty, is used to build the NextProtos
+	// specification of TLSConfig. One or the other must be specified.
+	// If neither are specified, listening operations will panic. If both
+	// are specified, Protocol will be prepended to the list specified
+	// in NextProtos.
+	Protocol string
+
+	// EnableDatagrams turns on support for unreliable datagrams (RFC
+	// 9221) if QuicConfig doesn't already specify a value, allowing
+	// Conn.SendDatagram and Conn.ReceiveDatagram to be used on accepted
+	// connections.
+	EnableDatagrams bool
+}
+
+func (c *ListenerConfig) tlsConfig() *tls.Config {
+	conf := c.TLSConfig.Clone()
+	if conf == nil {
+		conf = new(tls.Config)
+	}
+
+	if c.Protocol != "" {
+		conf.NextProtos = append([]string{c.Protocol}, conf.NextProtos...)
+	}
+
+	if len(conf.NextProtos) == 0 {
+		panic("no protocol specified")
+	}
+
+	return conf
+}
+
+func (c *ListenerConfig) quicConfig() *quic.Config {
+	conf := c.QuicConfig
+	if conf == nil {
+		conf = new(quic.Config)
+	} else {
+		clone := *conf
+		conf = &clone
+	}
+
+	if c.EnableDatagrams {
+		conf.EnableDatagrams = true
+	}
+
+	return conf
+}
+
+// Listen starts listening for QUIC connections on address.
+func (c *ListenerConfig) Listen(address string) (*Listener, error) {
+	listener, err := quic.ListenAddr(address, c.tlsConfig(), c.quicConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return newListener(listener), nil
+}
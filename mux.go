@@ -0,0 +1,162 @@
+package fquic
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// DefaultUnknownSignatureErrorCode is the error code used by Mux to
+// cancel both halves of a stream whose signature doesn't match any
+// registered handler, unless Mux.UnknownSignatureErrorCode is set.
+const DefaultUnknownSignatureErrorCode = 0x1
+
+// Mux dispatches incoming streams on a Conn to handlers based on a
+// length-prefixed signature read from the head of each stream, turning
+// a bare connection into a usable substrate for multiple sub-protocols
+// without every user having to reinvent stream framing.
+//
+// Mux is safe for concurrent use. Handlers may be registered before or
+// after Serve is called.
+type Mux struct {
+	conn *Conn
+
+	// UnknownSignatureErrorCode is the error code used to cancel both
+	// halves of a stream whose signature doesn't match a registered
+	// handler. If it is zero, DefaultUnknownSignatureErrorCode is used.
+	UnknownSignatureErrorCode uint64
+
+	mu          sync.RWMutex
+	handlers    map[string]func(*Stream) error
+	uniHandlers map[string]func(*Stream) error
+}
+
+// NewMux creates a Mux that dispatches streams accepted from conn.
+func NewMux(conn *Conn) *Mux {
+	return &Mux{
+		conn:        conn,
+		handlers:    make(map[string]func(*Stream) error),
+		uniHandlers: make(map[string]func(*Stream) error),
+	}
+}
+
+// Handle registers handler to be called, in its own goroutine, for each
+// incoming bidirectional stream whose signature is signature.
+func (m *Mux) Handle(signature []byte, handler func(*Stream) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers[string(signature)] = handler
+}
+
+// HandleUni registers handler to be called, in its own goroutine, for
+// each incoming unidirectional stream whose signature is signature.
+func (m *Mux) HandleUni(signature []byte, handler func(*Stream) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.uniHandlers[string(signature)] = handler
+}
+
+// Serve accepts streams from the underlying Conn until ctx is done or
+// accepting fails, dispatching each to its registered handler. The
+// returned error is whatever AcceptStream returned.
+func (m *Mux) Serve(ctx context.Context) error {
+	for {
+		s, err := m.conn.AcceptStream(ctx)
+		if err != nil {
+			return err
+		}
+
+		go m.dispatch(s)
+	}
+}
+
+func (m *Mux) dispatch(s *Stream) {
+	sig, err := readSignature(s)
+	if err != nil {
+		m.reject(s)
+		return
+	}
+
+	handlers := m.handlers
+	if s.send == nil {
+		handlers = m.uniHandlers
+	}
+
+	m.mu.RLock()
+	handler, ok := handlers[string(sig)]
+	m.mu.RUnlock()
+
+	if !ok {
+		m.reject(s)
+		return
+	}
+
+	handler(s)
+}
+
+func (m *Mux) reject(s *Stream) {
+	code := m.UnknownSignatureErrorCode
+	if code == 0 {
+		code = DefaultUnknownSignatureErrorCode
+	}
+
+	s.CloseRead(code)
+	s.CancelWrite(code)
+}
+
+// OpenStreamWithSignature opens a new stream, writes a length-prefixed
+// signature (a varint length followed by the signature bytes) to its
+// head, and returns it ready for further use. The peer's Mux reads this
+// prefix to decide which handler to dispatch the stream to.
+func (c *Conn) OpenStreamWithSignature(sig []byte, uni bool) (*Stream, error) {
+	s, err := c.NewStream(uni)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(sig)))
+
+	if _, err := s.Write(prefix[:n]); err != nil {
+		s.CancelWrite(0)
+		s.CloseRead(0)
+		return nil, err
+	}
+	if _, err := s.Write(sig); err != nil {
+		s.CancelWrite(0)
+		s.CloseRead(0)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func readSignature(s *Stream) ([]byte, error) {
+	length, err := binary.ReadUvarint(streamByteReader{s})
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, length)
+	if _, err := io.ReadFull(s, sig); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// streamByteReader adapts a *Stream to io.ByteReader one byte at a time,
+// so that reading a varint off the head of a stream doesn't buffer past
+// it and steal bytes the handler expects to read itself.
+type streamByteReader struct {
+	s *Stream
+}
+
+func (r streamByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.s, b[:])
+	return b[0], err
+}
@@ -0,0 +1,128 @@
+package fquic
+
+import (
+	"context"
+	"sync"
+)
+
+type poolKey struct {
+	network  string
+	address  string
+	protocol string
+}
+
+// Pool holds a set of pooled *Conns keyed by (network, address,
+// protocol) and hands out a healthy one on Get, redialing transparently
+// when the previous connection's session has closed or when it has hit
+// MaxStreamsPerConn. The network in the key is always "udp" today,
+// reserved for future dialing methods that accept one explicitly.
+//
+// Pool dials through its Dialer, which needs a protocol (Dialer.Protocol
+// or Dialer.TLSConfig.NextProtos): with a zero Dialer, dialing panics.
+// Apart from that, the zero value of Pool is ready to use; Dialer also
+// sets the TLS, quic-go, and idle-timeout behavior of pooled connections.
+type Pool struct {
+	// Dialer is used to dial new connections. Its Protocol field is
+	// part of the pooling key, so connections dialed with different
+	// protocols are never reused for each other.
+	Dialer Dialer
+
+	// MaxStreamsPerConn caps how many concurrently open streams a
+	// pooled connection may have before Get considers it full and
+	// dials a fresh connection instead of reusing it. Zero means no
+	// cap.
+	MaxStreamsPerConn int64
+
+	mu    sync.Mutex
+	conns map[poolKey][]*Conn
+}
+
+func (p *Pool) key(addr string) poolKey {
+	return poolKey{network: "udp", address: addr, protocol: p.Dialer.Protocol}
+}
+
+func isHealthy(c *Conn) bool {
+	select {
+	case <-c.Session().Context().Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Get returns a healthy pooled connection to addr, dialing a new one if
+// none of the existing connections for addr are healthy and have spare
+// capacity under MaxStreamsPerConn.
+func (p *Pool) Get(ctx context.Context, addr string) (*Conn, error) {
+	key := p.key(addr)
+
+	if found := p.reap(key); found != nil {
+		return found, nil
+	}
+
+	conn, err := p.Dialer.DialContext(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conns == nil {
+		p.conns = make(map[poolKey][]*Conn)
+	}
+	p.conns[key] = append(p.conns[key], conn)
+	return conn, nil
+}
+
+// reap prunes unhealthy connections for key and returns a healthy one
+// with spare capacity, if any, without holding p.mu for any longer than
+// it takes to scan the existing connections.
+func (p *Pool) reap(key poolKey) *Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	live := p.conns[key][:0]
+	var found *Conn
+	for _, c := range p.conns[key] {
+		if !isHealthy(c) {
+			continue
+		}
+
+		live = append(live, c)
+		if found == nil && (p.MaxStreamsPerConn <= 0 || c.NumOpenStreams() < p.MaxStreamsPerConn) {
+			found = c
+		}
+	}
+
+	if p.conns == nil {
+		p.conns = make(map[poolKey][]*Conn)
+	}
+	p.conns[key] = live
+
+	return found
+}
+
+// OpenStream is a convenience that gets a pooled connection to addr and
+// opens a stream on it. If the connection turns out to have just gone
+// unhealthy, it retries once against a fresh connection.
+func (p *Pool) OpenStream(ctx context.Context, addr string, uni bool) (*Stream, error) {
+	conn, err := p.Get(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := conn.NewStream(uni)
+	if err == nil {
+		return s, nil
+	}
+	if isHealthy(conn) {
+		return nil, err
+	}
+
+	conn, err = p.Get(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.NewStream(uni)
+}
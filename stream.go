@@ -0,0 +1,189 @@
+package fquic
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// DefaultReadLinger is the amount of time that Stream.Close waits after
+// closing the send side of a stream before cancelling the receive side,
+// giving the peer a chance to finish reading or close its own side first.
+const DefaultReadLinger = 5 * time.Second
+
+// ErrWriteOnReceiveOnly is returned by Write when called on a
+// receive-only unidirectional stream.
+var ErrWriteOnReceiveOnly = errors.New("fquic: write on receive-only stream")
+
+// ErrReadOnSendOnly is returned by Read when called on a send-only
+// unidirectional stream.
+var ErrReadOnSendOnly = errors.New("fquic: read on send-only stream")
+
+// Stream wraps the send and receive halves of a quic-go stream. quic-go
+// documents that Stream.Close must not be called concurrently with
+// Stream.Write, and that closing a bidirectional stream only shuts down
+// the send side, leaving the receive side open until the peer closes it
+// or an idle timeout fires. Stream works around both of these by
+// serializing Write and Close on the send side and by cancelling the
+// receive side itself, after a linger period, once Close is called.
+//
+// Stream is safe for concurrent use, including calling Close from a
+// different goroutine than the one calling Write.
+type Stream struct {
+	conn *Conn
+
+	send quic.SendStream
+	recv quic.ReceiveStream
+
+	// ReadLinger is the amount of time that Close waits after closing the
+	// send side before cancelling the receive side. If it is zero,
+	// DefaultReadLinger is used instead.
+	ReadLinger time.Duration
+
+	writeLock sync.Mutex
+	closer    sync.Once
+
+	finLock   sync.Mutex
+	writeDone bool
+	readDone  bool
+	finOnce   sync.Once
+}
+
+func newStream(conn *Conn, send quic.SendStream, recv quic.ReceiveStream) *Stream {
+	conn.streamOpened()
+
+	return &Stream{
+		conn:      conn,
+		send:      send,
+		recv:      recv,
+		writeDone: send == nil,
+		readDone:  recv == nil,
+	}
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	if s.send == nil {
+		return 0, ErrWriteOnReceiveOnly
+	}
+
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	return s.send.Write(p)
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	if s.recv == nil {
+		return 0, ErrReadOnSendOnly
+	}
+
+	return s.recv.Read(p)
+}
+
+// Close closes the send side of the stream, then, after ReadLinger (or
+// DefaultReadLinger if it is unset), cancels the receive side so that a
+// half-closed stream doesn't leak memory waiting on the peer or an idle
+// timeout. It is safe to call Close concurrently with Write.
+//
+// Callers that need finer control over the two halves should use
+// CloseWrite, CloseRead and CancelWrite instead; the Conn that produced
+// this Stream considers it outstanding (for Shutdown and IdleTimeout)
+// until both halves have been finalized, through whichever combination
+// of these methods is used.
+func (s *Stream) Close() (err error) {
+	s.closer.Do(func() {
+		err = s.CloseWrite()
+
+		if s.recv != nil {
+			linger := s.ReadLinger
+			if linger == 0 {
+				linger = DefaultReadLinger
+			}
+
+			time.AfterFunc(linger, func() {
+				s.recv.CancelRead(0)
+				s.markReadDone()
+			})
+		}
+	})
+	return err
+}
+
+// CloseWrite closes the send side of the stream without touching the
+// receive side. It is safe to call concurrently with Write.
+func (s *Stream) CloseWrite() error {
+	if s.send == nil {
+		return nil
+	}
+
+	s.writeLock.Lock()
+	err := s.send.Close()
+	s.writeLock.Unlock()
+
+	s.markWriteDone()
+	return err
+}
+
+// CancelWrite immediately aborts the send side of the stream with the
+// given error code, without sending the peer a graceful FIN the way
+// CloseWrite does. It is a no-op on a receive-only stream.
+func (s *Stream) CancelWrite(code uint64) {
+	if s.send != nil {
+		s.send.CancelWrite(quic.StreamErrorCode(code))
+	}
+	s.markWriteDone()
+}
+
+// CloseRead immediately cancels the receive side of the stream with the
+// given error code, without waiting for ReadLinger. It is a no-op on a
+// send-only stream.
+func (s *Stream) CloseRead(code uint64) {
+	if s.recv != nil {
+		s.recv.CancelRead(quic.StreamErrorCode(code))
+	}
+	s.markReadDone()
+}
+
+// StreamID returns the quic-go stream ID of the underlying stream.
+func (s *Stream) StreamID() quic.StreamID {
+	if s.send != nil {
+		return s.send.StreamID()
+	}
+	return s.recv.StreamID()
+}
+
+// markWriteDone and markReadDone record that the send or receive half of
+// the stream has been finalized by one of CloseWrite, CancelWrite or
+// CloseRead (Close goes through CloseWrite and, after its linger,
+// CloseRead's underlying cancel). Once both halves a Stream actually has
+// are finalized, the Stream reports itself closed to its Conn exactly
+// once, regardless of which combination of methods got it there.
+func (s *Stream) markWriteDone() {
+	s.finLock.Lock()
+	s.writeDone = true
+	done := s.writeDone && s.readDone
+	s.finLock.Unlock()
+
+	if done {
+		s.finish()
+	}
+}
+
+func (s *Stream) markReadDone() {
+	s.finLock.Lock()
+	s.readDone = true
+	done := s.writeDone && s.readDone
+	s.finLock.Unlock()
+
+	if done {
+		s.finish()
+	}
+}
+
+func (s *Stream) finish() {
+	s.finOnce.Do(func() {
+		s.conn.streamClosed()
+	})
+}